@@ -0,0 +1,330 @@
+package smap
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	lfBits  = 4
+	lfWidth = 1 << lfBits
+	lfMask  = lfWidth - 1
+)
+
+// LockFree is a concurrent map for read-mostly workloads where even RLock
+// contention on sMap shows up in profiles. It's backed by a hash-array-
+// mapped trie with a fixed 16-way fan-out per level (4 bits of hash
+// consumed per level, so a 64-bit hash is fully consumed after 16 levels).
+// Get walks the trie through atomic loads, taking a leaf's overflow read
+// lock only when that leaf's hash equals the looked-up key's hash. Set,
+// Delete and CAS install new nodes with a compare-and-swap on the parent
+// slot, retrying on conflict, splitting a leaf into an indirect node when a
+// new key collides with an existing one on the same slot. True full-hash
+// collisions (all levels consumed, or two different keys that hash equal)
+// fall back to a short overflow chain on the colliding leaf, guarded by a
+// per-leaf mutex.
+type LockFree[K comparable, V any] struct {
+	root atomic.Pointer[lfIndirect[K, V]]
+	hash func(K) uint64
+	size atomic.Int64
+}
+
+// lfIndirect is a branch node: a fixed-size array of atomic slot pointers
+type lfIndirect[K comparable, V any] struct {
+	slots [lfWidth]atomic.Pointer[lfNode[K, V]]
+}
+
+// lfNode is either a leaf entry (children == nil) or a branch one level
+// deeper (children != nil). A leaf additionally carries its own overflow
+// chain for keys that fully collide with it.
+type lfNode[K comparable, V any] struct {
+	hash     uint64
+	key      K
+	val      V
+	children *lfIndirect[K, V]
+
+	overflowMu sync.RWMutex
+	overflow   []lfOverflowEntry[K, V]
+}
+
+type lfOverflowEntry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+func (n *lfNode[K, V]) isBranch() bool {
+	return n != nil && n.children != nil
+}
+
+// NewLockFree creates a new LockFree map. An optional hash function can be
+// passed to control key placement in the trie; if omitted, the same default
+// hasher used by Sharded and Persistent is used.
+func NewLockFree[K comparable, V any](hash ...func(K) uint64) *LockFree[K, V] {
+	l := &LockFree[K, V]{}
+	if len(hash) > 0 && hash[0] != nil {
+		l.hash = hash[0]
+	} else {
+		l.hash = defaultHash[K]
+	}
+	l.root.Store(&lfIndirect[K, V]{})
+	return l
+}
+
+// Len returns the number of entries in the map
+func (l *LockFree[K, V]) Len() int {
+	return int(l.size.Load())
+}
+
+// Get walks the trie without locking any branch (a leaf's overflow chain is
+// read under its read lock); returns false if the key doesn't exist
+func (l *LockFree[K, V]) Get(k K) (V, bool) {
+	h := l.hash(k)
+	cur := l.root.Load()
+	shift := uint(0)
+	for cur != nil {
+		n := cur.slots[(h>>shift)&lfMask].Load()
+		if n == nil {
+			break
+		}
+		if n.isBranch() {
+			cur = n.children
+			shift += lfBits
+			continue
+		}
+		if n.key == k {
+			return n.val, true
+		}
+		if n.hash == h {
+			n.overflowMu.RLock()
+			for _, e := range n.overflow {
+				if e.key == k {
+					n.overflowMu.RUnlock()
+					return e.val, true
+				}
+			}
+			n.overflowMu.RUnlock()
+		}
+		break
+	}
+	var zero V
+	return zero, false
+}
+
+// Set sets a value for a given key (overwrites existing value)
+func (l *LockFree[K, V]) Set(k K, v V) {
+	l.upsert(k, v, false)
+}
+
+// CAS is a simple Check And Set, returns false if the key was already set
+func (l *LockFree[K, V]) CAS(k K, v V) bool {
+	return l.upsert(k, v, true)
+}
+
+// upsert installs k/v, splitting leaves into branches as needed, via a
+// compare-and-swap retry loop on the slot being touched. If onlyIfAbsent is
+// true, the key is left untouched (and false returned) when it already
+// exists, implementing CAS; otherwise it behaves like Set.
+func (l *LockFree[K, V]) upsert(k K, v V, onlyIfAbsent bool) bool {
+	h := l.hash(k)
+	for {
+		cur := l.root.Load()
+		shift := uint(0)
+		for {
+			slot := &cur.slots[(h>>shift)&lfMask]
+			old := slot.Load()
+			if old == nil {
+				nw := &lfNode[K, V]{hash: h, key: k, val: v}
+				if slot.CompareAndSwap(nil, nw) {
+					l.size.Add(1)
+					return true
+				}
+				break // lost the race, retry from root
+			}
+			if old.isBranch() {
+				cur = old.children
+				shift += lfBits
+				continue
+			}
+			if old.key == k {
+				if onlyIfAbsent {
+					return false
+				}
+				// hold the lock across the CAS, not just the snapshot: a
+				// concurrent overflow append/update on old also takes this
+				// lock for its whole mutation, so this keeps the two
+				// mutually exclusive instead of racing a stale copy in
+				old.overflowMu.Lock()
+				nw := &lfNode[K, V]{hash: h, key: k, val: v}
+				if len(old.overflow) > 0 {
+					nw.overflow = append([]lfOverflowEntry[K, V](nil), old.overflow...)
+				}
+				ok := slot.CompareAndSwap(old, nw)
+				old.overflowMu.Unlock()
+				if ok {
+					return true
+				}
+				break
+			}
+			if old.hash == h || shift+lfBits >= 64 {
+				// true collision, or the hash space is fully consumed:
+				// extend the overflow chain instead of splitting further
+				old.overflowMu.Lock()
+				if slot.Load() != old {
+					// old was swapped out (e.g. its primary key was
+					// overwritten/deleted) while we waited for the lock;
+					// mutating it now would silently lose this write
+					old.overflowMu.Unlock()
+					break // retry from root
+				}
+				idx := -1
+				for i, e := range old.overflow {
+					if e.key == k {
+						idx = i
+						break
+					}
+				}
+				switch {
+				case idx >= 0 && onlyIfAbsent:
+					old.overflowMu.Unlock()
+					return false
+				case idx >= 0:
+					old.overflow[idx].val = v
+				default:
+					old.overflow = append(old.overflow, lfOverflowEntry[K, V]{key: k, val: v})
+					l.size.Add(1)
+				}
+				old.overflowMu.Unlock()
+				return true
+			}
+			// different key, different hash at this prefix: split this slot
+			// into a deeper branch holding the old entry, then carry on
+			// inserting the new key into it
+			child := &lfIndirect[K, V]{}
+			child.slots[(old.hash>>(shift+lfBits))&lfMask].Store(old)
+			branch := &lfNode[K, V]{children: child}
+			if slot.CompareAndSwap(old, branch) {
+				cur = child
+				shift += lfBits
+				continue
+			}
+			break // lost the race, retry from root
+		}
+	}
+}
+
+// Delete deletes one or more of the keys. Non-existing keys are a no-op as with a normal map
+func (l *LockFree[K, V]) Delete(keys ...K) {
+	for _, k := range keys {
+		l.deleteOne(k)
+	}
+}
+
+func (l *LockFree[K, V]) deleteOne(k K) {
+	h := l.hash(k)
+	for {
+		cur := l.root.Load()
+		shift := uint(0)
+		for {
+			slot := &cur.slots[(h>>shift)&lfMask]
+			old := slot.Load()
+			if old == nil {
+				return
+			}
+			if old.isBranch() {
+				cur = old.children
+				shift += lfBits
+				continue
+			}
+			if old.key == k {
+				// the lock is held across the CAS (not just the read) so a
+				// concurrent overflow append/update on old -- which also
+				// takes this lock for its whole mutation -- can't race a
+				// stale snapshot into the replacement node below
+				old.overflowMu.Lock()
+				if len(old.overflow) == 0 {
+					ok := slot.CompareAndSwap(old, nil)
+					old.overflowMu.Unlock()
+					if ok {
+						l.size.Add(-1)
+						return
+					}
+					break // lost the race, retry from root
+				}
+				// promote the first overflow entry to take this leaf's place
+				// so the rest of the chain isn't discarded along with k
+				promoted := old.overflow[0]
+				rest := append([]lfOverflowEntry[K, V](nil), old.overflow[1:]...)
+				nw := &lfNode[K, V]{hash: h, key: promoted.key, val: promoted.val, overflow: rest}
+				ok := slot.CompareAndSwap(old, nw)
+				old.overflowMu.Unlock()
+				if ok {
+					l.size.Add(-1)
+					return
+				}
+				break // lost the race, retry from root
+			}
+			if old.hash == h {
+				old.overflowMu.Lock()
+				if slot.Load() != old {
+					// old was swapped out from under us while we waited for
+					// the lock; retry from root instead of mutating (or
+					// giving up on) a now-detached node
+					old.overflowMu.Unlock()
+					break
+				}
+				for i, e := range old.overflow {
+					if e.key == k {
+						old.overflow = append(old.overflow[:i], old.overflow[i+1:]...)
+						l.size.Add(-1)
+						break
+					}
+				}
+				old.overflowMu.Unlock()
+				return
+			}
+			return
+		}
+	}
+}
+
+// All returns a snapshot iterator that walks the trie without locking any
+// branch (each leaf's overflow chain is copied under its read lock before it
+// is yielded), mirroring sync.Map.Range semantics: a concurrent write during the
+// walk may or may not be observed, depending on whether it lands ahead of or
+// behind the walk's current position.
+func (l *LockFree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		walkLockFree(l.root.Load(), yield)
+	}
+}
+
+func walkLockFree[K comparable, V any](n *lfIndirect[K, V], yield func(K, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	for i := range n.slots {
+		leaf := n.slots[i].Load()
+		if leaf == nil {
+			continue
+		}
+		if leaf.isBranch() {
+			if !walkLockFree(leaf.children, yield) {
+				return false
+			}
+			continue
+		}
+		if !yield(leaf.key, leaf.val) {
+			return false
+		}
+		leaf.overflowMu.RLock()
+		overflow := append([]lfOverflowEntry[K, V](nil), leaf.overflow...)
+		leaf.overflowMu.RUnlock()
+		for _, e := range overflow {
+			if !yield(e.key, e.val) {
+				return false
+			}
+		}
+	}
+	return true
+}
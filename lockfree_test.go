@@ -0,0 +1,152 @@
+package smap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/EVODelavega/smap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFreeGetSetDelete(t *testing.T) {
+	lm := smap.NewLockFree[string, int]()
+	require.Equal(t, 0, lm.Len())
+
+	lm.Set("a", 1)
+	lm.Set("b", 2)
+	v, ok := lm.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+	require.Equal(t, 2, lm.Len())
+
+	lm.Set("a", 10)
+	v, ok = lm.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 10, v)
+	require.Equal(t, 2, lm.Len())
+
+	lm.Delete("a")
+	_, ok = lm.Get("a")
+	require.False(t, ok)
+	require.Equal(t, 1, lm.Len())
+}
+
+func TestLockFreeCAS(t *testing.T) {
+	lm := smap.NewLockFree[string, int]()
+	require.True(t, lm.CAS("a", 1))
+	require.False(t, lm.CAS("a", 2))
+	v, _ := lm.Get("a")
+	require.Equal(t, 1, v)
+}
+
+func TestLockFreeManyKeys(t *testing.T) {
+	lm := smap.NewLockFree[int, int]()
+	const n = 2000
+	for i := 0; i < n; i++ {
+		lm.Set(i, i*2)
+	}
+	require.Equal(t, n, lm.Len())
+	for i := 0; i < n; i++ {
+		v, ok := lm.Get(i)
+		require.True(t, ok)
+		require.Equal(t, i*2, v)
+	}
+	seen := 0
+	for range lm.All() {
+		seen++
+	}
+	require.Equal(t, n, seen)
+}
+
+func TestLockFreeHashCollisions(t *testing.T) {
+	// a constant hash forces every key into the same overflow chain
+	lm := smap.NewLockFree[string, int](func(string) uint64 { return 1 })
+
+	lm.Set("a", 1)
+	lm.Set("b", 2)
+	lm.Set("c", 3)
+	require.Equal(t, 3, lm.Len())
+
+	va, ok := lm.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, va)
+	vb, ok := lm.Get("b")
+	require.True(t, ok)
+	require.Equal(t, 2, vb)
+
+	// overwriting the primary entry must not drop the rest of the chain
+	lm.Set("a", 10)
+	require.Equal(t, 3, lm.Len())
+	va, ok = lm.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 10, va)
+	vb, ok = lm.Get("b")
+	require.True(t, ok)
+	require.Equal(t, 2, vb)
+	vc, ok := lm.Get("c")
+	require.True(t, ok)
+	require.Equal(t, 3, vc)
+
+	require.False(t, lm.CAS("b", 99))
+
+	// deleting the primary entry must promote the rest of the chain, not drop it
+	lm.Delete("a")
+	require.Equal(t, 2, lm.Len())
+	_, ok = lm.Get("a")
+	require.False(t, ok)
+	vb, ok = lm.Get("b")
+	require.True(t, ok)
+	require.Equal(t, 2, vb)
+	vc, ok = lm.Get("c")
+	require.True(t, ok)
+	require.Equal(t, 3, vc)
+
+	lm.Delete("b")
+	lm.Delete("c")
+	require.Equal(t, 0, lm.Len())
+}
+
+func TestLockFreeConcurrentOverwriteRacesCollisionAppend(t *testing.T) {
+	// all keys collide on the same leaf, so this exercises the race between
+	// overwriting a leaf's primary key and a concurrent append to its
+	// overflow chain
+	for i := 0; i < 500; i++ {
+		lm := smap.NewLockFree[int, int](func(int) uint64 { return 1 })
+		lm.Set(0, 0)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			lm.Set(0, 999)
+		}()
+		go func() {
+			defer wg.Done()
+			lm.Set(1, 111)
+		}()
+		wg.Wait()
+
+		require.Equal(t, 2, lm.Len())
+		v, ok := lm.Get(1)
+		require.True(t, ok)
+		require.Equal(t, 111, v)
+	}
+}
+
+func TestLockFreeConcurrentSetGet(t *testing.T) {
+	lm := smap.NewLockFree[int, int]()
+	var wg sync.WaitGroup
+	const workers = 8
+	const perWorker = 200
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(base int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				lm.Set(base+i, i)
+			}
+		}(w * perWorker)
+	}
+	wg.Wait()
+	require.Equal(t, workers*perWorker, lm.Len())
+}
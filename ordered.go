@@ -0,0 +1,410 @@
+package smap
+
+import "sync"
+
+// avlNode is a node in the AVL tree backing OrderedMap, kept balanced and
+// ordered according to the map's less func.
+type avlNode[K comparable, V any] struct {
+	key    K
+	val    V
+	left   *avlNode[K, V]
+	right  *avlNode[K, V]
+	height int
+}
+
+// OrderedMap is a sync-safe map backed by a self-balancing AVL tree, keeping
+// keys in the order defined by less. Unlike sMap, whose Iter pays
+// O(n log n) to sort the full key set on every call, OrderedMap keeps keys
+// sorted as they're inserted, so in-order traversal (RankedIter, Range) is
+// O(n) with no up-front sort.
+type OrderedMap[K comparable, V any] struct {
+	mu   *sync.RWMutex
+	root *avlNode[K, V]
+	less func(a, b K) bool
+	size int
+}
+
+// NewOrdered creates a new OrderedMap, ordered using the given less func
+func NewOrdered[K comparable, V any](less func(a, b K) bool, init map[K]V) *OrderedMap[K, V] {
+	o := &OrderedMap[K, V]{
+		mu:   &sync.RWMutex{},
+		less: less,
+	}
+	o.Merge(init, true) // overwrite doesn't make a difference but we can skip pointless lookups
+	return o
+}
+
+func avlHeight[K comparable, V any](n *avlNode[K, V]) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func avlMaxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func avlBalanceFactor[K comparable, V any](n *avlNode[K, V]) int {
+	if n == nil {
+		return 0
+	}
+	return avlHeight(n.left) - avlHeight(n.right)
+}
+
+func avlRotateRight[K comparable, V any](y *avlNode[K, V]) *avlNode[K, V] {
+	x := y.left
+	y.left = x.right
+	x.right = y
+	y.height = 1 + avlMaxInt(avlHeight(y.left), avlHeight(y.right))
+	x.height = 1 + avlMaxInt(avlHeight(x.left), avlHeight(x.right))
+	return x
+}
+
+func avlRotateLeft[K comparable, V any](x *avlNode[K, V]) *avlNode[K, V] {
+	y := x.right
+	x.right = y.left
+	y.left = x
+	x.height = 1 + avlMaxInt(avlHeight(x.left), avlHeight(x.right))
+	y.height = 1 + avlMaxInt(avlHeight(y.left), avlHeight(y.right))
+	return y
+}
+
+func avlRebalance[K comparable, V any](n *avlNode[K, V]) *avlNode[K, V] {
+	n.height = 1 + avlMaxInt(avlHeight(n.left), avlHeight(n.right))
+	bf := avlBalanceFactor(n)
+	if bf > 1 {
+		if avlBalanceFactor(n.left) < 0 {
+			n.left = avlRotateLeft(n.left)
+		}
+		return avlRotateRight(n)
+	}
+	if bf < -1 {
+		if avlBalanceFactor(n.right) > 0 {
+			n.right = avlRotateRight(n.right)
+		}
+		return avlRotateLeft(n)
+	}
+	return n
+}
+
+func avlInsert[K comparable, V any](n *avlNode[K, V], k K, v V, less func(a, b K) bool) (*avlNode[K, V], bool) {
+	if n == nil {
+		return &avlNode[K, V]{key: k, val: v, height: 1}, true
+	}
+	var isNew bool
+	switch {
+	case less(k, n.key):
+		n.left, isNew = avlInsert(n.left, k, v, less)
+	case less(n.key, k):
+		n.right, isNew = avlInsert(n.right, k, v, less)
+	default:
+		n.val = v
+		return n, false
+	}
+	return avlRebalance(n), isNew
+}
+
+func avlMin[K comparable, V any](n *avlNode[K, V]) *avlNode[K, V] {
+	for n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func avlDelete[K comparable, V any](n *avlNode[K, V], k K, less func(a, b K) bool) (*avlNode[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+	var removed bool
+	switch {
+	case less(k, n.key):
+		n.left, removed = avlDelete(n.left, k, less)
+	case less(n.key, k):
+		n.right, removed = avlDelete(n.right, k, less)
+	default:
+		removed = true
+		if n.left == nil {
+			n = n.right
+		} else if n.right == nil {
+			n = n.left
+		} else {
+			succ := avlMin(n.right)
+			n.key = succ.key
+			n.val = succ.val
+			n.right, _ = avlDelete(n.right, succ.key, less)
+		}
+	}
+	if !removed || n == nil {
+		return n, removed
+	}
+	return avlRebalance(n), true
+}
+
+func avlFind[K comparable, V any](n *avlNode[K, V], k K, less func(a, b K) bool) *avlNode[K, V] {
+	for n != nil {
+		switch {
+		case less(k, n.key):
+			n = n.left
+		case less(n.key, k):
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+// avlFloor finds the greatest node with a key <= k
+func avlFloor[K comparable, V any](n *avlNode[K, V], k K, less func(a, b K) bool) *avlNode[K, V] {
+	var best *avlNode[K, V]
+	for n != nil {
+		switch {
+		case less(k, n.key):
+			n = n.left
+		case less(n.key, k):
+			best = n
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return best
+}
+
+// avlCeiling finds the smallest node with a key >= k
+func avlCeiling[K comparable, V any](n *avlNode[K, V], k K, less func(a, b K) bool) *avlNode[K, V] {
+	var best *avlNode[K, V]
+	for n != nil {
+		switch {
+		case less(k, n.key):
+			best = n
+			n = n.left
+		case less(n.key, k):
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return best
+}
+
+func avlInorder[K comparable, V any](n *avlNode[K, V], cb func(K, V)) {
+	if n == nil {
+		return
+	}
+	avlInorder(n.left, cb)
+	cb(n.key, n.val)
+	avlInorder(n.right, cb)
+}
+
+// Len returns the number of entries in the map
+func (o *OrderedMap[K, V]) Len() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.size
+}
+
+// Get simply gets the value for a given key, returns false if the key doesn't exist
+func (o *OrderedMap[K, V]) Get(k K) (V, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	n := avlFind(o.root, k, o.less)
+	if n == nil {
+		var zero V
+		return zero, false
+	}
+	return n.val, true
+}
+
+// Set sets a value for a given key (overwrites existing value)
+func (o *OrderedMap[K, V]) Set(k K, v V) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var isNew bool
+	o.root, isNew = avlInsert(o.root, k, v, o.less)
+	if isNew {
+		o.size++
+	}
+}
+
+// CAS is a simple Check And Set, returns false if the key was already set
+func (o *OrderedMap[K, V]) CAS(k K, v V) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if avlFind(o.root, k, o.less) != nil {
+		return false
+	}
+	o.root, _ = avlInsert(o.root, k, v, o.less)
+	o.size++
+	return true
+}
+
+// Delete deletes one or more of the keys. Non-existing keys are a no-op as with a normal map
+func (o *OrderedMap[K, V]) Delete(keys ...K) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, k := range keys {
+		var removed bool
+		o.root, removed = avlDelete(o.root, k, o.less)
+		if removed {
+			o.size--
+		}
+	}
+}
+
+// Merge merges a given map into this type
+func (o *OrderedMap[K, V]) Merge(m map[K]V, overwrite bool) {
+	if len(m) == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for k, v := range m {
+		if !overwrite && avlFind(o.root, k, o.less) != nil {
+			continue
+		}
+		var isNew bool
+		o.root, isNew = avlInsert(o.root, k, v, o.less)
+		if isNew {
+			o.size++
+		}
+	}
+}
+
+// Clone creates a copy
+func (o *OrderedMap[K, V]) Clone() *OrderedMap[K, V] {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	c := &OrderedMap[K, V]{
+		mu:   &sync.RWMutex{},
+		less: o.less,
+	}
+	avlInorder(o.root, func(k K, v V) {
+		var isNew bool
+		c.root, isNew = avlInsert(c.root, k, v, c.less)
+		if isNew {
+			c.size++
+		}
+	})
+	return c
+}
+
+// Min returns the smallest key/value pair, false if the map is empty
+func (o *OrderedMap[K, V]) Min() (K, V, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	var k K
+	var v V
+	if o.root == nil {
+		return k, v, false
+	}
+	n := avlMin(o.root)
+	return n.key, n.val, true
+}
+
+// Max returns the largest key/value pair, false if the map is empty
+func (o *OrderedMap[K, V]) Max() (K, V, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	var k K
+	var v V
+	if o.root == nil {
+		return k, v, false
+	}
+	n := o.root
+	for n.right != nil {
+		n = n.right
+	}
+	return n.key, n.val, true
+}
+
+// Floor returns the greatest key <= k, false if no such key exists
+func (o *OrderedMap[K, V]) Floor(k K) (K, V, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	n := avlFloor(o.root, k, o.less)
+	if n == nil {
+		var zk K
+		var zv V
+		return zk, zv, false
+	}
+	return n.key, n.val, true
+}
+
+// Ceiling returns the smallest key >= k, false if no such key exists
+func (o *OrderedMap[K, V]) Ceiling(k K) (K, V, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	n := avlCeiling(o.root, k, o.less)
+	if n == nil {
+		var zk K
+		var zv V
+		return zk, zv, false
+	}
+	return n.key, n.val, true
+}
+
+// RankedIter returns an iterator that walks the map in less order via an
+// in-order tree traversal, without the up-front sort sMap.Iter needs.
+// After iterating over the values, Close must be called!
+func (o *OrderedMap[K, V]) RankedIter() *rIter[K, V] {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	keys := make([]K, 0, o.size)
+	m := make(map[K]V, o.size)
+	avlInorder(o.root, func(k K, v V) {
+		keys = append(keys, k)
+		m[k] = v
+	})
+	return &rIter[K, V]{
+		m:    m,
+		keys: keys,
+	}
+}
+
+// Range returns an iterator over the keys in [lo, hi] (or (lo, hi) when
+// inclusive is false), walked in less order. After iterating over the
+// values, Close must be called!
+func (o *OrderedMap[K, V]) Range(lo, hi K, inclusive bool) *rIter[K, V] {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	aboveLo := func(k K) bool {
+		if inclusive {
+			return !o.less(k, lo)
+		}
+		return o.less(lo, k)
+	}
+	belowHi := func(k K) bool {
+		if inclusive {
+			return !o.less(hi, k)
+		}
+		return o.less(k, hi)
+	}
+	keys := make([]K, 0)
+	m := make(map[K]V)
+	var walk func(n *avlNode[K, V])
+	walk = func(n *avlNode[K, V]) {
+		if n == nil {
+			return
+		}
+		if o.less(lo, n.key) {
+			walk(n.left)
+		}
+		if aboveLo(n.key) && belowHi(n.key) {
+			keys = append(keys, n.key)
+			m[n.key] = n.val
+		}
+		if o.less(n.key, hi) {
+			walk(n.right)
+		}
+	}
+	walk(o.root)
+	return &rIter[K, V]{
+		m:    m,
+		keys: keys,
+	}
+}
@@ -0,0 +1,131 @@
+package smap_test
+
+import (
+	"testing"
+
+	"github.com/EVODelavega/smap"
+	"github.com/stretchr/testify/require"
+)
+
+func intLess(a, b int) bool {
+	return a < b
+}
+
+func TestOrderedInitGetSet(t *testing.T) {
+	init := map[int]string{
+		3: "three",
+		1: "one",
+		2: "two",
+	}
+	om := smap.NewOrdered[int, string](intLess, init)
+	require.Equal(t, len(init), om.Len())
+	for k, v := range init {
+		sv, ok := om.Get(k)
+		require.True(t, ok)
+		require.Equal(t, v, sv)
+	}
+	om.Set(4, "four")
+	v, ok := om.Get(4)
+	require.True(t, ok)
+	require.Equal(t, "four", v)
+}
+
+func TestOrderedCASDeleteMerge(t *testing.T) {
+	om := smap.NewOrdered[int, string](intLess, nil)
+	require.True(t, om.CAS(1, "one"))
+	require.False(t, om.CAS(1, "ONE"))
+
+	om.Merge(map[int]string{1: "ONE", 2: "two"}, false)
+	v, _ := om.Get(1)
+	require.Equal(t, "one", v) // not overwritten
+	v, _ = om.Get(2)
+	require.Equal(t, "two", v)
+
+	om.Delete(1)
+	_, ok := om.Get(1)
+	require.False(t, ok)
+	require.Equal(t, 1, om.Len())
+}
+
+func TestOrderedMinMaxFloorCeiling(t *testing.T) {
+	init := map[int]string{}
+	for _, k := range []int{10, 20, 30, 40, 50} {
+		init[k] = "v"
+	}
+	om := smap.NewOrdered[int, string](intLess, init)
+
+	minK, _, ok := om.Min()
+	require.True(t, ok)
+	require.Equal(t, 10, minK)
+
+	maxK, _, ok := om.Max()
+	require.True(t, ok)
+	require.Equal(t, 50, maxK)
+
+	fk, _, ok := om.Floor(25)
+	require.True(t, ok)
+	require.Equal(t, 20, fk)
+
+	ck, _, ok := om.Ceiling(25)
+	require.True(t, ok)
+	require.Equal(t, 30, ck)
+
+	fk, _, ok = om.Floor(10)
+	require.True(t, ok)
+	require.Equal(t, 10, fk)
+
+	_, _, ok = om.Floor(5)
+	require.False(t, ok)
+
+	_, _, ok = om.Ceiling(55)
+	require.False(t, ok)
+}
+
+func TestOrderedRankedIterAndRange(t *testing.T) {
+	init := map[int]string{}
+	for _, k := range []int{5, 3, 8, 1, 4, 7, 9, 2, 6} {
+		init[k] = "v"
+	}
+	om := smap.NewOrdered[int, string](intLess, init)
+
+	it := om.RankedIter()
+	keys := make([]int, 0, len(init))
+	for it.Next() {
+		k, err := it.Key()
+		require.NoError(t, err)
+		keys = append(keys, k)
+	}
+	it.Close()
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, keys)
+
+	rit := om.Range(3, 7, true)
+	rkeys := make([]int, 0)
+	for rit.Next() {
+		k, _ := rit.Key()
+		rkeys = append(rkeys, k)
+	}
+	rit.Close()
+	require.Equal(t, []int{3, 4, 5, 6, 7}, rkeys)
+
+	rit = om.Range(3, 7, false)
+	rkeys = rkeys[:0]
+	for rit.Next() {
+		k, _ := rit.Key()
+		rkeys = append(rkeys, k)
+	}
+	rit.Close()
+	require.Equal(t, []int{4, 5, 6}, rkeys)
+}
+
+func TestOrderedClone(t *testing.T) {
+	om := smap.NewOrdered[int, string](intLess, map[int]string{1: "one", 2: "two"})
+	clone := om.Clone()
+	om.Delete(1)
+	om.Set(3, "three")
+
+	_, ok := clone.Get(1)
+	require.True(t, ok)
+	_, ok = clone.Get(3)
+	require.False(t, ok)
+	require.Equal(t, 2, clone.Len())
+}
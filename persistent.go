@@ -0,0 +1,437 @@
+package smap
+
+import (
+	"math/bits"
+	"reflect"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	persistBits  = 5
+	persistWidth = 1 << persistBits
+	persistMask  = persistWidth - 1
+)
+
+// Persistent is an immutable, structurally-shared map backed by a
+// hash-array-mapped trie (HAMT). Set/Delete never mutate a trie node in
+// place: they build a new root that shares every subtree unaffected by the
+// change with the old one (O(log32 n) nodes copied), then swap it in with a
+// compare-and-swap on the atomic root pointer. Clone is therefore O(1): it
+// just hands out a new handle pointing at the current root, which then
+// evolves independently of the original.
+type Persistent[K comparable, V any] struct {
+	root *atomic.Pointer[pnode[K, V]]
+	hash func(K) uint64
+}
+
+// pnode is a node in the trie: either a branch, addressed by a 32-bit bitmap
+// of populated slots (5 bits of hash per level) plus a compacted children
+// slice, or a leaf holding a small collision list of entries that share a
+// hash prefix.
+type pnode[K comparable, V any] struct {
+	bitmap   uint32
+	children []*pnode[K, V]
+	entries  []pentry[K, V]
+}
+
+type pentry[K comparable, V any] struct {
+	hash uint64
+	key  K
+	val  V
+}
+
+func (n *pnode[K, V]) isLeaf() bool {
+	return n != nil && n.entries != nil
+}
+
+// NewPersistent creates a new Persistent map, optionally seeded with init and
+// using a custom hash function. If no hash function is given, the same
+// default hasher used by Sharded is used.
+func NewPersistent[K comparable, V any](init map[K]V, hash ...func(K) uint64) *Persistent[K, V] {
+	p := &Persistent[K, V]{
+		root: &atomic.Pointer[pnode[K, V]]{},
+	}
+	if len(hash) > 0 && hash[0] != nil {
+		p.hash = hash[0]
+	} else {
+		p.hash = defaultHash[K]
+	}
+	for k, v := range init {
+		p.Set(k, v)
+	}
+	return p
+}
+
+// Len returns the number of entries in the map
+func (p *Persistent[K, V]) Len() int {
+	return countNode(p.root.Load())
+}
+
+func countNode[K comparable, V any](n *pnode[K, V]) int {
+	if n == nil {
+		return 0
+	}
+	if n.isLeaf() {
+		return len(n.entries)
+	}
+	total := 0
+	for _, c := range n.children {
+		total += countNode(c)
+	}
+	return total
+}
+
+// Get simply gets the value for a given key, returns false if the key doesn't exist
+func (p *Persistent[K, V]) Get(k K) (V, bool) {
+	return getNode(p.root.Load(), p.hash(k), k, 0)
+}
+
+func getNode[K comparable, V any](n *pnode[K, V], h uint64, k K, shift uint) (V, bool) {
+	var zero V
+	if n == nil {
+		return zero, false
+	}
+	if n.isLeaf() {
+		for _, e := range n.entries {
+			if e.key == k {
+				return e.val, true
+			}
+		}
+		return zero, false
+	}
+	idx := uint32(h>>shift) & persistMask
+	bit := uint32(1) << idx
+	if n.bitmap&bit == 0 {
+		return zero, false
+	}
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+	return getNode(n.children[pos], h, k, shift+persistBits)
+}
+
+// Set sets a value for a given key (overwrites existing value). The change is
+// applied as a compare-and-swap loop against the root: concurrent writers
+// never block each other, they retry against whichever root wins the race.
+func (p *Persistent[K, V]) Set(k K, v V) {
+	h := p.hash(k)
+	for {
+		old := p.root.Load()
+		nw := setNode(old, h, k, v, 0)
+		if p.root.CompareAndSwap(old, nw) {
+			return
+		}
+	}
+}
+
+func setNode[K comparable, V any](n *pnode[K, V], h uint64, k K, v V, shift uint) *pnode[K, V] {
+	if n == nil {
+		return &pnode[K, V]{entries: []pentry[K, V]{{hash: h, key: k, val: v}}}
+	}
+	if n.isLeaf() {
+		for _, e := range n.entries {
+			if e.key == k {
+				return setLeafEntry(n, h, k, v)
+			}
+		}
+		if shift >= 64 {
+			// hash space exhausted: degrade to a pure collision list
+			return setLeafEntry(n, h, k, v)
+		}
+		// n's entries only share the bits consumed below shift -- the bits
+		// *at* shift (what placed n in its current slot's sibling position)
+		// are still unknown, so they may already diverge from the new key
+		// right here. All of n's entries share the same hash (a leaf only
+		// ever holds more than one entry once the hash is fully collided),
+		// so a single representative is enough to tell.
+		existingHash := n.entries[0].hash
+		idxOld := uint32(existingHash>>shift) & persistMask
+		idxNew := uint32(h>>shift) & persistMask
+		if idxOld != idxNew {
+			// diverge right here: a two-child branch, no need to go deeper
+			newLeaf := &pnode[K, V]{entries: []pentry[K, V]{{hash: h, key: k, val: v}}}
+			bitOld, bitNew := uint32(1)<<idxOld, uint32(1)<<idxNew
+			if idxOld < idxNew {
+				return &pnode[K, V]{bitmap: bitOld | bitNew, children: []*pnode[K, V]{n, newLeaf}}
+			}
+			return &pnode[K, V]{bitmap: bitOld | bitNew, children: []*pnode[K, V]{newLeaf, n}}
+		}
+		// still colliding at this level: push both one level deeper under a
+		// single-child branch and let them try to diverge there instead
+		var deeper *pnode[K, V]
+		for _, e := range n.entries {
+			deeper = setNode(deeper, e.hash, e.key, e.val, shift+persistBits)
+		}
+		deeper = setNode(deeper, h, k, v, shift+persistBits)
+		return &pnode[K, V]{bitmap: uint32(1) << idxOld, children: []*pnode[K, V]{deeper}}
+	}
+	idx := uint32(h>>shift) & persistMask
+	bit := uint32(1) << idx
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		children := make([]*pnode[K, V], len(n.children)+1)
+		copy(children, n.children[:pos])
+		children[pos] = setNode[K, V](nil, h, k, v, shift+persistBits)
+		copy(children[pos+1:], n.children[pos:])
+		return &pnode[K, V]{bitmap: n.bitmap | bit, children: children}
+	}
+	children := make([]*pnode[K, V], len(n.children))
+	copy(children, n.children)
+	children[pos] = setNode(n.children[pos], h, k, v, shift+persistBits)
+	return &pnode[K, V]{bitmap: n.bitmap, children: children}
+}
+
+func setLeafEntry[K comparable, V any](n *pnode[K, V], h uint64, k K, v V) *pnode[K, V] {
+	entries := make([]pentry[K, V], 0, len(n.entries)+1)
+	found := false
+	for _, e := range n.entries {
+		if e.key == k {
+			entries = append(entries, pentry[K, V]{hash: h, key: k, val: v})
+			found = true
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if !found {
+		entries = append(entries, pentry[K, V]{hash: h, key: k, val: v})
+	}
+	return &pnode[K, V]{entries: entries}
+}
+
+// CAS is a simple Check And Set, returns false if the key was already set
+func (p *Persistent[K, V]) CAS(k K, v V) bool {
+	h := p.hash(k)
+	for {
+		old := p.root.Load()
+		if _, ok := getNode(old, h, k, 0); ok {
+			return false
+		}
+		nw := setNode(old, h, k, v, 0)
+		if p.root.CompareAndSwap(old, nw) {
+			return true
+		}
+	}
+}
+
+// Delete deletes one or more of the keys. Non-existing keys are a no-op as with a normal map
+func (p *Persistent[K, V]) Delete(keys ...K) {
+	for _, k := range keys {
+		h := p.hash(k)
+		for {
+			old := p.root.Load()
+			nw, removed := deleteNode(old, h, k, 0)
+			if !removed {
+				break
+			}
+			if p.root.CompareAndSwap(old, nw) {
+				break
+			}
+		}
+	}
+}
+
+func deleteNode[K comparable, V any](n *pnode[K, V], h uint64, k K, shift uint) (*pnode[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+	if n.isLeaf() {
+		idx := -1
+		for i, e := range n.entries {
+			if e.key == k {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return n, false
+		}
+		if len(n.entries) == 1 {
+			return nil, true
+		}
+		entries := make([]pentry[K, V], 0, len(n.entries)-1)
+		entries = append(entries, n.entries[:idx]...)
+		entries = append(entries, n.entries[idx+1:]...)
+		return &pnode[K, V]{entries: entries}, true
+	}
+	idx := uint32(h>>shift) & persistMask
+	bit := uint32(1) << idx
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+	child, removed := deleteNode(n.children[pos], h, k, shift+persistBits)
+	if !removed {
+		return n, false
+	}
+	if child != nil {
+		children := make([]*pnode[K, V], len(n.children))
+		copy(children, n.children)
+		children[pos] = child
+		return &pnode[K, V]{bitmap: n.bitmap, children: children}, true
+	}
+	// the child slot is now empty: drop it, and if the one child left is a
+	// leaf, return that leaf in n's place so leaves don't sit under pointless
+	// chains
+	if len(n.children) == 1 {
+		return nil, true
+	}
+	children := make([]*pnode[K, V], 0, len(n.children)-1)
+	children = append(children, n.children[:pos]...)
+	children = append(children, n.children[pos+1:]...)
+	if len(children) == 1 && children[0].isLeaf() {
+		return children[0], true
+	}
+	return &pnode[K, V]{bitmap: n.bitmap &^ bit, children: children}, true
+}
+
+// Merge merges a given map into this type
+func (p *Persistent[K, V]) Merge(m map[K]V, overwrite bool) {
+	if len(m) == 0 {
+		return
+	}
+	for k, v := range m {
+		if !overwrite {
+			p.CAS(k, v)
+			continue
+		}
+		p.Set(k, v)
+	}
+}
+
+// Clone creates a new handle pointing at the current root. This is O(1): no
+// trie nodes are copied, the two handles simply diverge from here as each is
+// mutated.
+func (p *Persistent[K, V]) Clone() *Persistent[K, V] {
+	c := &Persistent[K, V]{
+		root: &atomic.Pointer[pnode[K, V]]{},
+		hash: p.hash,
+	}
+	c.root.Store(p.root.Load())
+	return c
+}
+
+// Keys returns a slice of all keys
+func (p *Persistent[K, V]) Keys() []K {
+	ks := make([]K, 0, p.Len())
+	collectKeys(p.root.Load(), &ks)
+	return ks
+}
+
+func collectKeys[K comparable, V any](n *pnode[K, V], out *[]K) {
+	if n == nil {
+		return
+	}
+	if n.isLeaf() {
+		for _, e := range n.entries {
+			*out = append(*out, e.key)
+		}
+		return
+	}
+	for _, c := range n.children {
+		collectKeys(c, out)
+	}
+}
+
+// Raw returns a copy of the underlying data as a standard map[K]V
+func (p *Persistent[K, V]) Raw() map[K]V {
+	root := p.root.Load()
+	cpy := make(map[K]V, countNode(root))
+	collectMap(root, cpy)
+	return cpy
+}
+
+func collectMap[K comparable, V any](n *pnode[K, V], out map[K]V) {
+	if n == nil {
+		return
+	}
+	if n.isLeaf() {
+		for _, e := range n.entries {
+			out[e.key] = e.val
+		}
+		return
+	}
+	for _, c := range n.children {
+		collectMap(c, out)
+	}
+}
+
+// Iter returns a snapshot iterator, sorted using sort.SliceStable if a sort
+// func is given. After iterating over the values, Close must be called!
+func (p *Persistent[K, V]) Iter(f func(a, b K) bool) *rIter[K, V] {
+	root := p.root.Load()
+	cpy := make(map[K]V, countNode(root))
+	collectMap(root, cpy)
+	keys := make([]K, 0, len(cpy))
+	for k := range cpy {
+		keys = append(keys, k)
+	}
+	if f != nil {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return f(keys[i], keys[j])
+		})
+	}
+	return &rIter[K, V]{
+		m:    cpy,
+		keys: keys,
+	}
+}
+
+// Diff compares p against other and returns the keys that were added,
+// removed, or changed. Subtrees whose root pointers are identical between the
+// two tries are skipped entirely without being walked -- that identity-
+// equality shortcut is the whole point of structural sharing.
+func (p *Persistent[K, V]) Diff(other *Persistent[K, V]) (added, removed, changed map[K]V) {
+	added = map[K]V{}
+	removed = map[K]V{}
+	changed = map[K]V{}
+	diffNode(p.root.Load(), other.root.Load(), added, removed, changed)
+	return added, removed, changed
+}
+
+func diffNode[K comparable, V any](a, b *pnode[K, V], added, removed, changed map[K]V) {
+	if a == b {
+		return // identical subtree (including both nil): nothing changed below here
+	}
+	if a == nil {
+		collectMap(b, added)
+		return
+	}
+	if b == nil {
+		collectMap(a, removed)
+		return
+	}
+	if a.isLeaf() || b.isLeaf() {
+		am := map[K]V{}
+		bm := map[K]V{}
+		collectMap(a, am)
+		collectMap(b, bm)
+		for k, av := range am {
+			bv, ok := bm[k]
+			if !ok {
+				removed[k] = av
+			} else if !reflect.DeepEqual(av, bv) {
+				changed[k] = bv
+			}
+		}
+		for k, bv := range bm {
+			if _, ok := am[k]; !ok {
+				added[k] = bv
+			}
+		}
+		return
+	}
+	union := a.bitmap | b.bitmap
+	for idx := uint32(0); idx < persistWidth; idx++ {
+		bit := uint32(1) << idx
+		if union&bit == 0 {
+			continue
+		}
+		var ac, bc *pnode[K, V]
+		if a.bitmap&bit != 0 {
+			ac = a.children[bits.OnesCount32(a.bitmap&(bit-1))]
+		}
+		if b.bitmap&bit != 0 {
+			bc = b.children[bits.OnesCount32(b.bitmap&(bit-1))]
+		}
+		diffNode(ac, bc, added, removed, changed)
+	}
+}
@@ -0,0 +1,120 @@
+package smap_test
+
+import (
+	"testing"
+
+	"github.com/EVODelavega/smap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentInitGetSet(t *testing.T) {
+	init := map[string]int{
+		"one": 1,
+		"two": 2,
+	}
+	pm := smap.NewPersistent[string, int](init)
+	require.Equal(t, len(init), pm.Len())
+	for k, v := range init {
+		sv, ok := pm.Get(k)
+		require.True(t, ok)
+		require.Equal(t, v, sv)
+	}
+	pm.Set("three", 3)
+	v, ok := pm.Get("three")
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+}
+
+func TestPersistentSharedHashPrefixSplitsProperly(t *testing.T) {
+	// a hash that only varies in its low 4 bits forces every key below to
+	// collide repeatedly as they're pushed deeper into the trie, exercising
+	// the leaf-split path several levels down
+	pm := smap.NewPersistent[int, int](nil, func(k int) uint64 {
+		return uint64(k % 16)
+	})
+	for i := 0; i < 64; i++ {
+		pm.Set(i, i*2)
+	}
+	require.Equal(t, 64, pm.Len())
+	for i := 0; i < 64; i++ {
+		v, ok := pm.Get(i)
+		require.True(t, ok)
+		require.Equal(t, i*2, v)
+	}
+}
+
+func TestPersistentCASDelete(t *testing.T) {
+	pm := smap.NewPersistent[string, int](nil)
+	require.True(t, pm.CAS("a", 1))
+	require.False(t, pm.CAS("a", 2))
+	pm.Delete("a")
+	_, ok := pm.Get("a")
+	require.False(t, ok)
+}
+
+func TestPersistentCloneIsStructurallyIndependent(t *testing.T) {
+	init := map[int]string{}
+	for i := 0; i < 200; i++ {
+		init[i] = "v"
+	}
+	pm := smap.NewPersistent[int, string](init)
+	clone := pm.Clone()
+
+	pm.Set(1, "changed")
+	pm.Delete(2)
+	pm.Set(500, "new")
+
+	v, ok := clone.Get(1)
+	require.True(t, ok)
+	require.Equal(t, "v", v)
+	_, ok = clone.Get(2)
+	require.True(t, ok)
+	_, ok = clone.Get(500)
+	require.False(t, ok)
+
+	require.Equal(t, 200, clone.Len())
+	require.Equal(t, 200, pm.Len())
+}
+
+func TestPersistentMergeIter(t *testing.T) {
+	pm := smap.NewPersistent[int, string](map[int]string{1: "one", 2: "two"})
+	pm.Merge(map[int]string{2: "TWO", 3: "three"}, false)
+	v, _ := pm.Get(2)
+	require.Equal(t, "two", v) // not overwritten
+	v, _ = pm.Get(3)
+	require.Equal(t, "three", v)
+
+	it := pm.Iter(func(a, b int) bool {
+		return a < b
+	})
+	keys := make([]int, 0, 3)
+	for it.Next() {
+		k, err := it.Key()
+		require.NoError(t, err)
+		keys = append(keys, k)
+	}
+	it.Close()
+	require.Equal(t, []int{1, 2, 3}, keys)
+}
+
+func TestPersistentDiff(t *testing.T) {
+	base := smap.NewPersistent[string, int](map[string]int{
+		"a": 1,
+		"b": 2,
+		"c": 3,
+	})
+	other := base.Clone()
+	other.Set("b", 20)
+	other.Delete("c")
+	other.Set("d", 4)
+
+	added, removed, changed := base.Diff(other)
+	require.Equal(t, map[string]int{"d": 4}, added)
+	require.Equal(t, map[string]int{"c": 3}, removed)
+	require.Equal(t, map[string]int{"b": 20}, changed)
+
+	sameAdded, sameRemoved, sameChanged := base.Diff(base.Clone())
+	require.Empty(t, sameAdded)
+	require.Empty(t, sameRemoved)
+	require.Empty(t, sameChanged)
+}
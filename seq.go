@@ -0,0 +1,89 @@
+package smap
+
+import (
+	"iter"
+	"sort"
+)
+
+// All returns an iterator over key-value pairs in s, in iter.Seq2 form, so
+// callers can write `for k, v := range sm.All() { ... }` instead of juggling
+// Iter/Next/Close. Iteration order is non-deterministic, like ranging over a
+// normal map. The RLock is held for the duration of the yield loop and is
+// released whether the consumer breaks out early or the loop runs to
+// completion.
+func (s *sMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for k, v := range s.m {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Keys2 returns an iterator over the keys in s, in iter.Seq form. Named to
+// avoid clashing with the existing Keys method, which returns a []K.
+func (s *sMap[K, V]) Keys2() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for k := range s.m {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values in s, in iter.Seq form
+func (s *sMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, v := range s.m {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Sorted returns an iterator over key-value pairs in s ordered using
+// sort.SliceStable over the keys, in iter.Seq2 form. As with All, the RLock
+// is held for the duration of the yield loop and released when the consumer
+// breaks out or the loop completes.
+func (s *sMap[K, V]) Sorted(less func(a, b K) bool) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		keys := make([]K, 0, len(s.m))
+		for k := range s.m {
+			keys = append(keys, k)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			return less(keys[i], keys[j])
+		})
+		for _, k := range keys {
+			if !yield(k, s.m[k]) {
+				return
+			}
+		}
+	}
+}
+
+// Collect builds a new sMap from seq, mirroring the stdlib maps.Collect
+func Collect[K comparable, V any](seq iter.Seq2[K, V]) *sMap[K, V] {
+	s := New[K, V](nil)
+	Insert(s, seq)
+	return s
+}
+
+// Insert adds every key-value pair produced by seq into s, mirroring the
+// stdlib maps.Insert
+func Insert[K comparable, V any](s *sMap[K, V], seq iter.Seq2[K, V]) {
+	for k, v := range seq {
+		s.Set(k, v)
+	}
+}
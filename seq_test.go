@@ -0,0 +1,91 @@
+package smap_test
+
+import (
+	"testing"
+
+	"github.com/EVODelavega/smap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAll(t *testing.T) {
+	init := map[string]int{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+	sm := smap.New(init)
+	seen := map[string]int{}
+	for k, v := range sm.All() {
+		seen[k] = v
+	}
+	require.Equal(t, init, seen)
+}
+
+func TestKeys2AndValues(t *testing.T) {
+	init := map[string]int{
+		"one": 1,
+		"two": 2,
+	}
+	sm := smap.New(init)
+
+	keys := map[string]struct{}{}
+	for k := range sm.Keys2() {
+		keys[k] = struct{}{}
+	}
+	require.Equal(t, len(init), len(keys))
+	for k := range init {
+		_, ok := keys[k]
+		require.True(t, ok)
+	}
+
+	total := 0
+	for v := range sm.Values() {
+		total += v
+	}
+	require.Equal(t, 3, total)
+}
+
+func TestSorted(t *testing.T) {
+	init := map[int]string{
+		3: "three",
+		1: "one",
+		2: "two",
+	}
+	sm := smap.New(init)
+	keys := make([]int, 0, len(init))
+	for k, v := range sm.Sorted(func(a, b int) bool { return a < b }) {
+		keys = append(keys, k)
+		require.Equal(t, init[k], v)
+	}
+	require.Equal(t, []int{1, 2, 3}, keys)
+}
+
+func TestSortedBreaksEarly(t *testing.T) {
+	sm := smap.New(map[int]string{1: "one", 2: "two", 3: "three"})
+	count := 0
+	for range sm.Sorted(func(a, b int) bool { return a < b }) {
+		count++
+		if count == 1 {
+			break
+		}
+	}
+	require.Equal(t, 1, count)
+	// map must still be usable: the RLock held during the loop was released,
+	// so taking the write lock here doesn't deadlock
+	sm.Set(1, "one")
+	require.Equal(t, 3, sm.Len())
+}
+
+func TestCollectInsert(t *testing.T) {
+	init := map[string]int{
+		"one": 1,
+		"two": 2,
+	}
+	sm := smap.New(init)
+	collected := smap.Collect(sm.All())
+	require.Equal(t, init, collected.Raw())
+
+	other := smap.New[string, int](nil)
+	smap.Insert(other, collected.All())
+	require.Equal(t, init, other.Raw())
+}
@@ -0,0 +1,236 @@
+package smap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Sharded is a lock-striped concurrent map. Keys are partitioned across a
+// fixed number of shards, each guarded by its own sync.RWMutex, so that
+// readers and writers touching different shards never contend on the same
+// lock. This trades the simplicity of sMap for better throughput under heavy
+// concurrent access spread across many keys.
+type Sharded[K comparable, V any] struct {
+	shards []*shard[K, V]
+	hash   func(K) uint64
+}
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+// NewSharded creates a new Sharded map with shardCount shards (a value below
+// 1 is treated as 1). An optional hash function can be passed to control how
+// keys are distributed across shards; if omitted, a default hasher is used
+// that handles the common comparable key types (strings, []byte, the builtin
+// integer types) via FNV-1a and falls back to hashing the key's fmt.Sprint
+// representation for anything else.
+func NewSharded[K comparable, V any](init map[K]V, shardCount int, hash ...func(K) uint64) *Sharded[K, V] {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	r := &Sharded[K, V]{
+		shards: make([]*shard[K, V], shardCount),
+	}
+	if len(hash) > 0 && hash[0] != nil {
+		r.hash = hash[0]
+	} else {
+		r.hash = defaultHash[K]
+	}
+	for i := range r.shards {
+		r.shards[i] = &shard[K, V]{
+			m: make(map[K]V),
+		}
+	}
+	r.Merge(init, true) // overwrite doesn't make a difference but we can skip pointless lookups
+	return r
+}
+
+// defaultHash hashes a key using FNV-1a. Common builtin types are written to
+// the hasher directly; anything else falls back to its fmt.Sprint
+// representation.
+func defaultHash[K comparable](k K) uint64 {
+	h := fnv.New64a()
+	switch v := any(k).(type) {
+	case string:
+		h.Write([]byte(v))
+	case []byte:
+		h.Write(v)
+	case int, int8, int16, int32, int64:
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(reflect.ValueOf(v).Int()))
+		h.Write(b[:])
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], reflect.ValueOf(v).Uint())
+		h.Write(b[:])
+	default:
+		fmt.Fprint(h, v)
+	}
+	return h.Sum64()
+}
+
+func (s *Sharded[K, V]) shardFor(k K) *shard[K, V] {
+	return s.shards[s.hash(k)%uint64(len(s.shards))]
+}
+
+// Len returns the total number of entries across all shards
+func (s *Sharded[K, V]) Len() int {
+	total := 0
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		total += len(sh.m)
+		sh.mu.RUnlock()
+	}
+	return total
+}
+
+// Merge merges a given map into this type
+func (s *Sharded[K, V]) Merge(m map[K]V, overwrite bool) {
+	if len(m) == 0 {
+		return
+	}
+	for k, v := range m {
+		if !overwrite {
+			s.CAS(k, v) // atomic check-and-set: don't clobber a concurrent writer
+			continue
+		}
+		s.Set(k, v)
+	}
+}
+
+// Clone creates a copy with the same shard count and hash function
+func (s *Sharded[K, V]) Clone() *Sharded[K, V] {
+	return NewSharded[K, V](s.Raw(), len(s.shards), s.hash)
+}
+
+// Get simply gets the value for a given key, returns false if the key doesn't exist
+func (s *Sharded[K, V]) Get(k K) (V, bool) {
+	sh := s.shardFor(k)
+	sh.mu.RLock()
+	v, ok := sh.m[k]
+	sh.mu.RUnlock()
+	return v, ok
+}
+
+// Set sets a value for a given key (overwrites existing value)
+func (s *Sharded[K, V]) Set(k K, v V) {
+	sh := s.shardFor(k)
+	sh.mu.Lock()
+	sh.m[k] = v
+	sh.mu.Unlock()
+}
+
+// CAS is a simple Check And Set, returns false if the key was not set
+func (s *Sharded[K, V]) CAS(k K, v V) bool {
+	sh := s.shardFor(k)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.m[k]; ok {
+		return false
+	}
+	sh.m[k] = v
+	return true
+}
+
+// Delete deletes one or more of the keys. Non-existing keys are a no-op as with a normal map
+func (s *Sharded[K, V]) Delete(keys ...K) {
+	for _, k := range keys {
+		sh := s.shardFor(k)
+		sh.mu.Lock()
+		delete(sh.m, k)
+		sh.mu.Unlock()
+	}
+}
+
+// Keys returns a slice of all keys, aggregated across all shards
+func (s *Sharded[K, V]) Keys() []K {
+	ks := make([]K, 0, s.Len())
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for k := range sh.m {
+			ks = append(ks, k)
+		}
+		sh.mu.RUnlock()
+	}
+	return ks
+}
+
+// Raw returns a copy of the underlying data as a standard map[K]V, aggregated across all shards
+func (s *Sharded[K, V]) Raw() map[K]V {
+	ret := make(map[K]V, s.Len())
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for k, v := range sh.m {
+			ret[k] = v
+		}
+		sh.mu.RUnlock()
+	}
+	return ret
+}
+
+// Filter returns a map containing the elements that matched the filter callback argument
+func (s *Sharded[K, V]) Filter(cb func(K, V) bool) map[K]V {
+	ret := make(map[K]V)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for k, v := range sh.m {
+			if cb(k, v) {
+				ret[k] = v
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return ret
+}
+
+// Iter returns a snapshot iterator over all shards, sorted using sort.SliceStable
+// if a sort func is given. Because the data lives behind multiple independently
+// locked shards, locking them all for the lifetime of the iterator (as sMap.Iter
+// does for its single lock) isn't practical, so Iter behaves like sMap.RIter:
+// the shards are copied one at a time and the original map can be freely updated
+// while the iterator is in use. Close must still be called to release the iterator.
+func (s *Sharded[K, V]) Iter(f func(a, b K) bool) *rIter[K, V] {
+	return s.iterShards(s.shards, f)
+}
+
+// RIter is an alias for Iter: for a Sharded map, iteration is always snapshot-based
+func (s *Sharded[K, V]) RIter(f func(a, b K) bool) *rIter[K, V] {
+	return s.iterShards(s.shards, f)
+}
+
+// IterShard returns a snapshot iterator scoped to a single shard, so that parallel
+// consumers can each process a different shard concurrently without contending on
+// the same lock
+func (s *Sharded[K, V]) IterShard(i int, f func(a, b K) bool) *rIter[K, V] {
+	return s.iterShards(s.shards[i:i+1], f)
+}
+
+func (s *Sharded[K, V]) iterShards(shards []*shard[K, V], f func(a, b K) bool) *rIter[K, V] {
+	cpy := make(map[K]V)
+	for _, sh := range shards {
+		sh.mu.RLock()
+		for k, v := range sh.m {
+			cpy[k] = v
+		}
+		sh.mu.RUnlock()
+	}
+	keys := make([]K, 0, len(cpy))
+	for k := range cpy {
+		keys = append(keys, k)
+	}
+	if f != nil {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return f(keys[i], keys[j])
+		})
+	}
+	return &rIter[K, V]{
+		m:    cpy,
+		keys: keys,
+	}
+}
@@ -0,0 +1,110 @@
+package smap_test
+
+import (
+	"testing"
+
+	"github.com/EVODelavega/smap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedInitAndGet(t *testing.T) {
+	init := map[string]int{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+	sm := smap.NewSharded[string, int](init, 4)
+	require.Equal(t, len(init), sm.Len())
+	for k, v := range init {
+		sv, ok := sm.Get(k)
+		require.True(t, ok)
+		require.Equal(t, v, sv)
+	}
+}
+
+func TestShardedSetCASDelete(t *testing.T) {
+	sm := smap.NewSharded[string, int](nil, 8)
+	require.True(t, sm.CAS("a", 1))
+	require.False(t, sm.CAS("a", 2)) // already set
+	v, ok := sm.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	sm.Set("a", 3)
+	v, ok = sm.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+
+	sm.Delete("a")
+	_, ok = sm.Get("a")
+	require.False(t, ok)
+}
+
+func TestShardedMergeFilterRawKeys(t *testing.T) {
+	init := map[int]string{
+		1: "one",
+		2: "two",
+		3: "three",
+	}
+	sm := smap.NewSharded[int, string](init, 3)
+
+	sm.Merge(map[int]string{3: "THREE", 4: "four"}, false)
+	v, _ := sm.Get(3)
+	require.Equal(t, "three", v) // not overwritten
+	v, _ = sm.Get(4)
+	require.Equal(t, "four", v)
+
+	keys := sm.Keys()
+	require.Equal(t, 4, len(keys))
+
+	raw := sm.Raw()
+	require.Equal(t, 4, len(raw))
+
+	even := sm.Filter(func(k int, _ string) bool {
+		return k%2 == 0
+	})
+	require.Equal(t, 2, len(even))
+}
+
+func TestShardedCloneIsIndependent(t *testing.T) {
+	sm := smap.NewSharded[string, int](map[string]int{"a": 1, "b": 2}, 2)
+	clone := sm.Clone()
+	sm.Delete("a")
+	_, ok := sm.Get("a")
+	require.False(t, ok)
+	cv, ok := clone.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, cv)
+}
+
+func TestShardedIterAndIterShard(t *testing.T) {
+	init := map[int]string{
+		1: "one", 2: "two", 3: "three", 4: "four", 5: "five",
+	}
+	sm := smap.NewSharded[int, string](init, 3)
+
+	it := sm.Iter(func(a, b int) bool {
+		return a < b
+	})
+	seen := make([]int, 0, len(init))
+	for it.Next() {
+		k, err := it.Key()
+		require.NoError(t, err)
+		seen = append(seen, k)
+	}
+	it.Close()
+	require.Equal(t, len(init), len(seen))
+	for i := 1; i < len(seen); i++ {
+		require.True(t, seen[i-1] < seen[i])
+	}
+
+	total := 0
+	for i := 0; i < 3; i++ {
+		sit := sm.IterShard(i, nil)
+		for sit.Next() {
+			total++
+		}
+		sit.Close()
+	}
+	require.Equal(t, len(init), total)
+}